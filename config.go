@@ -0,0 +1,96 @@
+package newrelicEvents
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+)
+
+// Region selects which New Relic collector datacenter events are posted to.
+type Region string
+
+const (
+	RegionUS Region = "US"
+	RegionEU Region = "EU"
+)
+
+const (
+	usBaseURL = "https://insights-collector.newrelic.com"
+	euBaseURL = "https://insights-collector.eu01.nr-data.net"
+
+	defaultUserAgent = "newrelic-events-go"
+)
+
+// Config controls how NewWithConfig builds a Newrelic client.
+type Config struct {
+	Region     Region
+	BaseURL    string
+	HTTPClient *http.Client
+	UserAgent  string
+}
+
+// Option sets a field on a Config passed to NewWithConfig.
+type Option func(*Config)
+
+// WithRegion selects the New Relic collector datacenter; ignored if
+// WithBaseURL is also given. Defaults to RegionUS.
+func WithRegion(r Region) Option {
+	return func(c *Config) { c.Region = r }
+}
+
+// WithBaseURL overrides the collector URL entirely, for proxies or mock
+// servers. Takes precedence over WithRegion.
+func WithBaseURL(url string) Option {
+	return func(c *Config) { c.BaseURL = url }
+}
+
+// WithHTTPClient overrides the *http.Client used by the default Poster.
+func WithHTTPClient(client *http.Client) Option {
+	return func(c *Config) { c.HTTPClient = client }
+}
+
+// WithUserAgent overrides the User-Agent header sent with every POST.
+func WithUserAgent(ua string) Option {
+	return func(c *Config) { c.UserAgent = ua }
+}
+
+// NewWithConfig builds a Newrelic client for accountID/license, applying any
+// Options over the defaults (RegionUS, http.DefaultClient, the library's
+// own User-Agent). New is a thin wrapper calling this with no Options.
+func NewWithConfig(accountID, license string, opts ...Option) *Newrelic {
+	cfg := Config{
+		Region:     RegionUS,
+		HTTPClient: http.DefaultClient,
+		UserAgent:  defaultUserAgent,
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+
+	base := cfg.BaseURL
+	if base == "" {
+		base = regionBaseURL(cfg.Region)
+	}
+
+	return &Newrelic{
+		Poster: StandardPost(cfg.HTTPClient),
+		URL:    fmt.Sprintf("%s/v1/accounts/%s/events", base, accountID),
+		data: dataStore{
+			Mutex: &sync.Mutex{},
+			buf:   newBatchBuffer(),
+		},
+		license:      license,
+		userAgent:    cfg.UserAgent,
+		MaxEventSize: defaultMaxEventSize,
+		MaxBatchSize: defaultMaxBatchSize,
+		Store:        NewMemoryStore(),
+		Metrics:      noopMetrics{},
+	}
+}
+
+func regionBaseURL(r Region) string {
+	if r == RegionEU {
+		return euBaseURL
+	}
+	return usBaseURL
+}
@@ -0,0 +1,31 @@
+package newrelicEvents
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrReservedKey is returned when a caller's attribute map sets a key that
+// New Relic assigns automatically (eventType, timestamp).
+var ErrReservedKey = errors.New("newrelicEvents: attribute key is reserved")
+
+// ErrTooManyAttributes is returned when an event carries more attributes
+// than New Relic Insights accepts on a single event.
+var ErrTooManyAttributes = errors.New("newrelicEvents: too many attributes")
+
+// ErrAttributeTooLarge is returned when an attribute name or string value
+// exceeds New Relic Insights' length limits.
+var ErrAttributeTooLarge = errors.New("newrelicEvents: attribute name or value too large")
+
+// ErrEventTooLarge is returned by RecordEvent when a single event's
+// marshaled JSON alone exceeds MaxEventSize. The event is never added to
+// the buffer, so it can never take the rest of a batch down with it.
+type ErrEventTooLarge struct {
+	Name  string
+	Size  int
+	Limit int
+}
+
+func (e *ErrEventTooLarge) Error() string {
+	return fmt.Sprintf("newrelicEvents: event %q is %d bytes, exceeds MaxEventSize of %d", e.Name, e.Size, e.Limit)
+}
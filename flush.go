@@ -0,0 +1,267 @@
+package newrelicEvents
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"math/rand"
+	"sync"
+	"time"
+)
+
+const (
+	defaultWorkerCount   = 2
+	defaultFlushInterval = 5 * time.Second
+
+	initialBackoff = 500 * time.Millisecond
+	maxBackoff     = 30 * time.Second
+	maxRetries     = 5
+)
+
+// Start begins a background flush loop and a pool of delivery workers
+// consuming prepared batches from an internal channel. FlushInterval
+// guarantees delivery even for buffers that never cross MaxBatchSize,
+// eliminating the need for callers to poll Sync(). ctx governs the
+// lifetime of the whole pipeline; Stop triggers a bounded, graceful
+// shutdown. Calling Start again without an intervening Stop returns an
+// error.
+func (n *Newrelic) Start(ctx context.Context) error {
+	if n.cancel != nil {
+		return errors.New("newrelicEvents: already started")
+	}
+	if n.WorkerCount <= 0 {
+		n.WorkerCount = defaultWorkerCount
+	}
+	if n.FlushInterval <= 0 {
+		n.FlushInterval = defaultFlushInterval
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	n.cancel = cancel
+	n.flushQueue = make(chan flushBatch, n.WorkerCount)
+
+	n.wg.Add(n.WorkerCount)
+	for i := 0; i < n.WorkerCount; i++ {
+		go n.deliveryWorker(runCtx)
+	}
+
+	n.wg.Add(1)
+	go n.flushTicker(runCtx)
+
+	return nil
+}
+
+// Stop signals the flush loop and delivery workers to shut down after one
+// last flush of any buffered events, then blocks until the queue drains or
+// ctx's deadline passes, whichever comes first.
+func (n *Newrelic) Stop(ctx context.Context) error {
+	if n.cancel == nil {
+		return nil
+	}
+	// Flip stopping before cancel so any RecordEvent/enqueueFlush that's
+	// about to hand a batch to flushQueue instead posts it synchronously;
+	// flushQueue itself is never closed, so a send can never race a close.
+	n.flushMu.Lock()
+	n.stopping = true
+	n.flushMu.Unlock()
+
+	n.cancel()
+	done := make(chan struct{})
+	go func() {
+		n.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		n.cancel = nil
+		n.flushMu.Lock()
+		n.stopping = false
+		// The delivery workers that were the only readers of flushQueue have
+		// all exited by now (wg.Wait returned), so nil it out rather than
+		// leaving it for enqueueOrPost to keep sending into: a future
+		// RecordEvent/enqueueFlush call would otherwise block forever once
+		// the buffered sends fill up, wedging this and any future Stop/Start
+		// behind flushMu. Start allocates a fresh channel on the next run.
+		n.flushQueue = nil
+		n.flushMu.Unlock()
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (n *Newrelic) flushTicker(ctx context.Context) {
+	defer n.wg.Done()
+	ticker := time.NewTicker(n.FlushInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			n.enqueueFlush()
+			return
+		case <-ticker.C:
+			n.enqueueFlush()
+		}
+	}
+}
+
+// flushBatch pairs a swapped-out batch buffer with how many events it
+// holds, so BatchesFlushed can still report an event count once the buffer
+// has crossed the worker-pool channel.
+type flushBatch struct {
+	buf    *bytes.Buffer
+	events int
+}
+
+// enqueueOrPost hands batch to a delivery worker if the flush pipeline is
+// running, or posts it synchronously otherwise - either because Start was
+// never called, or because Stop has begun shutting the pipeline down.
+// Checking "stopping" and sending share flushMu with Stop's own flip of
+// that flag, so a batch is never sent to flushQueue after delivery workers
+// have stopped reading from it.
+func (n *Newrelic) enqueueOrPost(batch *bytes.Buffer, events int) error {
+	n.flushMu.Lock()
+	if n.flushQueue != nil && !n.stopping {
+		n.flushQueue <- flushBatch{buf: batch, events: events}
+		n.flushMu.Unlock()
+		return nil
+	}
+	n.flushMu.Unlock()
+	return n._Post(batch, events)
+}
+
+// enqueueFlush swaps out the buffered data and hands it to a delivery
+// worker, independent of MaxBatchSize.
+func (n *Newrelic) enqueueFlush() {
+	n.data.Lock()
+	empty := n.data.count == 0
+	n.data.Unlock()
+	if empty {
+		return
+	}
+	buf, events := n.swapBuffer()
+	if err := n.enqueueOrPost(buf, events); err != nil && n.OnDropped != nil {
+		n.OnDropped("", nil, err)
+	}
+}
+
+func (n *Newrelic) deliveryWorker(ctx context.Context) {
+	defer n.wg.Done()
+	for {
+		select {
+		case batch := <-n.flushQueue:
+			n.deliverWithRetry(ctx, batch)
+		case <-ctx.Done():
+			// Once Stop flips "stopping", producers route new batches
+			// through enqueueOrPost's synchronous path instead of this
+			// channel, so one non-blocking drain pass is enough to pick
+			// up anything already queued before exiting.
+			n.drainQueue(ctx)
+			return
+		}
+	}
+}
+
+// drainQueue delivers whatever is already sitting in flushQueue without
+// blocking for more.
+func (n *Newrelic) drainQueue(ctx context.Context) {
+	for {
+		select {
+		case batch := <-n.flushQueue:
+			n.deliverWithRetry(ctx, batch)
+		default:
+			return
+		}
+	}
+}
+
+// deliverWithRetry gzips batch once, then posts it, retrying with
+// exponential backoff and jitter on retryable failures (429, 5xx) and
+// giving up immediately on permanent 4xx responses or once maxRetries is
+// exhausted. ctx cancellation cuts the backoff wait short so shutdown
+// doesn't hang. OnDropped, if set, is called with the batch that was
+// ultimately abandoned.
+func (n *Newrelic) deliverWithRetry(ctx context.Context, batch flushBatch) {
+	body, err := n.compress(batch.buf)
+	if err != nil {
+		n.Metrics.FlushErrors("compress")
+		if n.OnDropped != nil {
+			n.OnDropped("", body, err)
+		}
+		return
+	}
+	id, persisted := n.persist(body)
+
+	start := time.Now()
+	backoff := initialBackoff
+	var lastErr error
+retryLoop:
+	for attempt := 0; attempt <= maxRetries; attempt++ {
+		lastErr = n.postBody(body)
+		if lastErr == nil {
+			break retryLoop
+		}
+		if !isRetryable(lastErr) || attempt == maxRetries {
+			break retryLoop
+		}
+		wait := jitter(backoff)
+		var postErr *PostError
+		if errors.As(lastErr, &postErr) && postErr.RetryAfter > 0 {
+			wait = postErr.RetryAfter
+		}
+		select {
+		case <-ctx.Done():
+			break retryLoop
+		case <-time.After(wait):
+		}
+		backoff *= 2
+		if backoff > maxBackoff {
+			backoff = maxBackoff
+		}
+	}
+	n.Metrics.FlushDuration(time.Since(start))
+
+	n.forget(id, persisted, body)
+	if lastErr != nil {
+		n.Metrics.FlushErrors(flushErrorKind(lastErr))
+		if n.OnDropped != nil {
+			n.OnDropped("", body, lastErr)
+		}
+		return
+	}
+	n.Metrics.BatchesFlushed(len(body), batch.events)
+}
+
+// jitter returns a random duration in [d/2, d], so a pool of workers backing
+// off together doesn't retry in lockstep.
+func jitter(d time.Duration) time.Duration {
+	half := d / 2
+	return half + time.Duration(rand.Int63n(int64(half+1)))
+}
+
+func isRetryable(err error) bool {
+	var postErr *PostError
+	if errors.As(err, &postErr) {
+		return postErr.Retryable
+	}
+	// errors that never reached a response (timeouts, connection resets) are
+	// worth retrying; only a classified permanent 4xx is terminal.
+	return true
+}
+
+// flushState holds the Start/Stop lifecycle bookkeeping for Newrelic. It is
+// embedded by value into Newrelic so zero-value Newrelics (built directly,
+// without Start) behave exactly as before.
+type flushState struct {
+	FlushInterval time.Duration
+	WorkerCount   int
+
+	// flushMu guards flushQueue and stopping together, so a batch can never
+	// be sent to flushQueue after Stop has told delivery workers to stop
+	// reading from it.
+	flushMu    sync.Mutex
+	stopping   bool
+	flushQueue chan flushBatch
+	cancel     context.CancelFunc
+	wg         sync.WaitGroup
+}
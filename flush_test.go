@@ -0,0 +1,58 @@
+package newrelicEvents
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newTestClient returns a Newrelic wired to an always-succeeding Poster, so
+// tests exercise the flush pipeline without making network calls.
+func newTestClient() *Newrelic {
+	n := NewWithConfig("acct", "license")
+	n.Poster = func(req *http.Request) error { return nil }
+	n.MaxBatchSize = 1 // flush on every RecordEvent
+	n.FlushInterval = time.Millisecond
+	return n
+}
+
+// TestRecordEventDuringStop reproduces the send-on-closed-channel panic that
+// used to occur when RecordEvent raced flushTicker's shutdown: any send to
+// flushQueue after Stop began shutting the pipeline down must be rejected in
+// favor of posting synchronously, never panic.
+func TestRecordEventDuringStop(t *testing.T) {
+	n := newTestClient()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := n.Start(ctx); err != nil {
+		t.Fatalf("Start: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+					n.RecordEvent("test", map[string]interface{}{"n": 1})
+				}
+			}
+		}()
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer stopCancel()
+	if err := n.Stop(stopCtx); err != nil {
+		t.Fatalf("Stop: %v", err)
+	}
+	close(stop)
+	wg.Wait()
+}
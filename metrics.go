@@ -0,0 +1,53 @@
+package newrelicEvents
+
+import (
+	"errors"
+	"time"
+)
+
+// Metrics lets operators observe event recording and batch delivery without
+// wrapping every call site - alerting on queue-depth growth or drop rates,
+// for instance. Newrelic defaults to a no-op implementation; see the
+// prometheus subpackage for a promhttp-compatible one.
+type Metrics interface {
+	EventsRecorded(name string)
+	EventsDropped(name string, reason string)
+	BatchesFlushed(bytes int, events int)
+	FlushDuration(d time.Duration)
+	FlushErrors(kind string)
+}
+
+type noopMetrics struct{}
+
+func (noopMetrics) EventsRecorded(name string)               {}
+func (noopMetrics) EventsDropped(name string, reason string) {}
+func (noopMetrics) BatchesFlushed(bytes int, events int)     {}
+func (noopMetrics) FlushDuration(d time.Duration)            {}
+func (noopMetrics) FlushErrors(kind string)                  {}
+
+// flushErrorKind classifies a flush failure for the FlushErrors metric.
+func flushErrorKind(err error) string {
+	var postErr *PostError
+	if errors.As(err, &postErr) {
+		if postErr.Retryable {
+			return "retryable"
+		}
+		return "terminal"
+	}
+	return "transport"
+}
+
+// dropReason classifies a validateAttributes failure for the EventsDropped
+// metric.
+func dropReason(err error) string {
+	switch {
+	case errors.Is(err, ErrReservedKey):
+		return "reserved_key"
+	case errors.Is(err, ErrTooManyAttributes):
+		return "too_many_attributes"
+	case errors.Is(err, ErrAttributeTooLarge):
+		return "attribute_too_large"
+	default:
+		return "invalid_attributes"
+	}
+}
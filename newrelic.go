@@ -1,12 +1,11 @@
 package newrelicEvents
 
 import (
+	"bytes"
 	"compress/gzip"
-	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
 	"sync"
 	"time"
@@ -15,27 +14,61 @@ import (
 // 950kb (newrelic is 1MB max
 // no sane person would have a single 50kb message???
 // TODO: allow crazy things, because we are in a crazy world
-const maxSize = 950000
+const (
+	defaultMaxEventSize = 950000
+	defaultMaxBatchSize = 950000
+
+	// New Relic Insights custom event limits: 254 attributes per event,
+	// 255 byte attribute names, 4096 byte string attribute values.
+	maxAttributeCount    = 254
+	maxAttributeNameLen  = 255
+	maxAttributeValueLen = 4096
+)
+
+// reservedKeys are attribute names New Relic assigns automatically; callers
+// may not set them themselves.
+var reservedKeys = map[string]struct{}{
+	"eventType": {},
+	"timestamp": {},
+}
 
 ///////////////////////////////////////////////////////////////////////////
 
+// dataStore holds the batch currently being built. buf is pre-seeded with
+// the opening '[' of the JSON array; count tracks how many events it holds
+// so RecordEvent knows whether a leading comma is needed.
 type dataStore struct {
 	*sync.Mutex
-	Data string
+	buf   *bytes.Buffer
+	count int
+}
+
+func newBatchBuffer() *bytes.Buffer {
+	buf := new(bytes.Buffer)
+	buf.WriteByte('[')
+	return buf
+}
+
+// scratchPool holds *bytes.Buffer used to encode a single event (to measure
+// its size against MaxEventSize before committing it); compress also returns
+// each flushed batch buffer to it. Pooling these avoids per-event
+// allocations under high throughput.
+var scratchPool = sync.Pool{
+	New: func() interface{} { return new(bytes.Buffer) },
+}
+
+// gzipWriterPool holds reusable *gzip.Writer so flushing doesn't allocate a
+// fresh compressor and its internal tables on every batch.
+var gzipWriterPool = sync.Pool{
+	New: func() interface{} { return gzip.NewWriter(nil) },
 }
 
 ///////////////////////////////////////////////////////////////////////////
 
+// New is a thin wrapper around NewWithConfig for the common case: US region,
+// http.DefaultClient, no Options.
 func New(AccountID string, License string) *Newrelic {
-	return &Newrelic{
-		Poster: StandardPost(http.DefaultClient),
-		URL:    fmt.Sprintf("https://insights-collector.newrelic.com/v1/accounts/%s/events", AccountID),
-		data: dataStore{
-			Mutex: &sync.Mutex{},
-			Data:  "",
-		},
-		license: License,
-	}
+	return NewWithConfig(AccountID, License)
 }
 
 ///////////////////////////////////////////////////////////////////////////
@@ -43,9 +76,35 @@ func New(AccountID string, License string) *Newrelic {
 type Newrelic struct {
 	Poster func(req *http.Request) error
 
-	data    dataStore
-	URL     string
-	license string
+	// MaxEventSize rejects any single event whose marshaled JSON exceeds it,
+	// since an oversized event will never POST and would otherwise take its
+	// whole batch down with it. MaxBatchSize is the aggregate buffer size at
+	// which RecordEvent flushes. Both default to 950kb.
+	MaxEventSize int
+	MaxBatchSize int
+
+	// OnDropped, if set, is called when RecordEvent rejects an event over
+	// MaxEventSize, and with an empty name when a flushed batch is abandoned
+	// or cannot be persisted to or deleted from Store, so callers can log or
+	// re-route it. Events rejected by attribute validation are only reported
+	// through RecordEvent's returned error.
+	OnDropped func(name string, payload []byte, reason error)
+
+	// Store persists a batch between it being accepted and successfully
+	// POSTed, so a crash in between doesn't lose it. Defaults to an
+	// in-memory Store; use NewWithStore for a durable one.
+	Store Store
+
+	// Metrics observes event recording and batch delivery. Defaults to a
+	// no-op implementation.
+	Metrics Metrics
+
+	flushState
+
+	data      dataStore
+	URL       string
+	license   string
+	userAgent string
 }
 
 // RecordEvent will add the event to the queue of events that is thread safe, you can go RecordEvent
@@ -54,52 +113,159 @@ func (n *Newrelic) RecordEvent(Name string, in map[string]interface{}) error {
 		return errors.New("No Event Name")
 	}
 	if in == nil {
+		n.Metrics.EventsDropped(Name, "nil_data")
 		return errors.New("data is nil")
 	}
+	if err := validateAttributes(in); err != nil {
+		n.Metrics.EventsDropped(Name, dropReason(err))
+		return err
+	}
 	in["eventType"] = Name
+
+	scratch := scratchPool.Get().(*bytes.Buffer)
+	scratch.Reset()
+	defer scratchPool.Put(scratch)
+
+	if err := json.NewEncoder(scratch).Encode(in); err != nil {
+		n.Metrics.EventsDropped(Name, "marshal_error")
+		return err
+	}
+	// Encode appends a trailing newline; trim it so size accounting matches
+	// what actually gets written into the batch buffer.
+	encoded := bytes.TrimRight(scratch.Bytes(), "\n")
+
+	if len(encoded) > n.MaxEventSize {
+		dropErr := &ErrEventTooLarge{Name: Name, Size: len(encoded), Limit: n.MaxEventSize}
+		n.Metrics.EventsDropped(Name, "event_too_large")
+		if n.OnDropped != nil {
+			n.OnDropped(Name, append([]byte(nil), encoded...), dropErr)
+		}
+		return dropErr
+	}
+
+	n.data.Lock()
+	if n.data.count > 0 {
+		n.data.buf.WriteByte(',')
+	}
+	n.data.buf.Write(encoded)
+	n.data.count++
+	size := n.data.buf.Len()
+	n.data.Unlock()
+	n.Metrics.EventsRecorded(Name)
+
+	if size <= n.MaxBatchSize {
+		return nil
+	}
+
+	batch, events := n.swapBuffer()
+	return n.enqueueOrPost(batch, events)
+}
+
+// swapBuffer atomically replaces the in-progress batch buffer with a fresh
+// one and returns the full one for flushing, along with how many events it
+// holds.
+func (n *Newrelic) swapBuffer() (*bytes.Buffer, int) {
 	n.data.Lock()
 	defer n.data.Unlock()
-	leaderKey := ""
-	if len(n.data.Data) > 0 {
-		leaderKey = ","
+	full, events := n.data.buf, n.data.count
+	n.data.buf = newBatchBuffer()
+	n.data.count = 0
+	return full, events
+}
+
+// validateAttributes rejects reserved keys and enforces New Relic Insights'
+// per-event attribute count and length limits before an event is marshaled.
+func validateAttributes(in map[string]interface{}) error {
+	for k := range reservedKeys {
+		if _, ok := in[k]; ok {
+			return fmt.Errorf("%w: %q is set automatically by RecordEvent", ErrReservedKey, k)
+		}
 	}
-	marshledData, err := json.Marshal(in)
+	// +1 accounts for the eventType key RecordEvent adds after validation.
+	if len(in)+1 > maxAttributeCount {
+		return fmt.Errorf("%w: %d attributes, max %d", ErrTooManyAttributes, len(in)+1, maxAttributeCount)
+	}
+	for k, v := range in {
+		if len(k) > maxAttributeNameLen {
+			return fmt.Errorf("%w: attribute name %q is %d bytes, max %d", ErrAttributeTooLarge, k, len(k), maxAttributeNameLen)
+		}
+		if s, ok := v.(string); ok && len(s) > maxAttributeValueLen {
+			return fmt.Errorf("%w: value of %q is %d bytes, max %d", ErrAttributeTooLarge, k, len(s), maxAttributeValueLen)
+		}
+	}
+	return nil
+}
+
+// Estimated returns the current size in bytes of the buffered, not-yet-flushed
+// event data so callers can make their own flushing decisions.
+func (n *Newrelic) Estimated() int {
+	n.data.Lock()
+	defer n.data.Unlock()
+	return n.data.buf.Len()
+}
+
+// _Post closes out the batch's JSON array, gzips it, persists it to Store
+// and hands it to the designated Poster, deleting it from Store again once
+// the attempt resolves. batch is returned to scratchPool, so callers must
+// not retain it afterwards.
+func (n *Newrelic) _Post(batch *bytes.Buffer, events int) error {
+	body, err := n.compress(batch)
 	if err != nil {
+		n.Metrics.FlushErrors("compress")
 		return err
 	}
-	n.data.Data += fmt.Sprintf("%s%s", leaderKey, marshledData)
+	id, persisted := n.persist(body)
+
+	start := time.Now()
+	err = n.postBody(body)
+	n.Metrics.FlushDuration(time.Since(start))
+
+	n.forget(id, persisted, body)
+	if err != nil {
+		n.Metrics.FlushErrors(flushErrorKind(err))
+		return err
+	}
+	n.Metrics.BatchesFlushed(len(body), events)
+	return nil
+}
+
+// compress closes the batch's JSON array and gzips it into a standalone
+// byte slice, which is what lets deliverWithRetry send the same payload
+// again on a retryable failure without re-encoding.
+func (n *Newrelic) compress(batch *bytes.Buffer) ([]byte, error) {
+	batch.WriteByte(']')
+
+	gz := gzipWriterPool.Get().(*gzip.Writer)
+	defer gzipWriterPool.Put(gz)
 
-	if len(n.data.Data) > maxSize {
-		// copy data into function so we can safely reuse the memory incase post is Async
-		err = n._Post(n.data.Data)
-		n.data.Data = ""
+	// The body is not pooled: StandardPost reads it synchronously, but
+	// AsyncPost hands the request off to a goroutine that may still be
+	// reading it after this function returns, so it isn't safe to recycle.
+	body := new(bytes.Buffer)
+	gz.Reset(body)
+	if _, err := gz.Write(batch.Bytes()); err != nil {
+		return nil, err
 	}
-	return err
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	scratchPool.Put(batch)
+	return body.Bytes(), nil
 }
 
-// _Post is in charge of building the http Request and passing it on to the designated poster
-func (n *Newrelic) _Post(data string) error {
-	// wrap the hand made json array correctly for posting (don't know a faster way to perform this logic)
-	data = fmt.Sprintf("[%s]", data)
-	r, w := io.Pipe()
-	defer r.Close()
-	defer w.Close()
-	// reduce memory buffer usage by syncing through a channel as the content is read
-	// to perform the request
-	go func() {
-		zipper := gzip.NewWriter(w)
-		zipper.Write([]byte(data))
-		zipper.Flush()
-		w.Close()
-		zipper.Close()
-	}()
-	req, err := http.NewRequest("POST", n.URL, r)
+// postBody builds the http.Request for an already-gzipped payload and hands
+// it to the designated Poster.
+func (n *Newrelic) postBody(body []byte) error {
+	req, err := http.NewRequest("POST", n.URL, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
 	req.Header.Add("Content-Type", "application/json")
 	req.Header.Add("X-Insert-Key", n.license)
 	req.Header.Add("Content-Encoding", "gzip")
+	if n.userAgent != "" {
+		req.Header.Add("User-Agent", n.userAgent)
+	}
 	return n.Poster(req)
 }
 
@@ -107,47 +273,6 @@ func (n *Newrelic) _Post(data string) error {
 
 // Sync performs a force Post to newrelic disregarding waiting for max buffer size
 func (n *Newrelic) Sync() error {
-	n.data.Lock()
-	defer n.data.Unlock()
-	return n._Post(n.data.Data)
-}
-
-///////////////////////////////////////////////////////////////////////////
-
-func StandardPost(client *http.Client) func(*http.Request) error {
-	return func(req *http.Request) error {
-		ctx, canFunc := context.WithTimeout(context.Background(), time.Second*30)
-		defer canFunc()
-		req = req.WithContext(ctx)
-		resp, err := client.Do(req)
-		if err != nil {
-			return err
-		}
-		defer resp.Body.Close()
-		if resp.StatusCode != 200 {
-			return fmt.Errorf("Bad Response: %d - %s", resp.StatusCode, resp.Status)
-		}
-		return nil
-	}
-}
-
-///////////////////////////////////////////////////////////////////////////
-
-func AsyncPost(ctx context.Context, client http.Client, errorLog io.Writer) func(*http.Request) error {
-	return func(req *http.Request) error {
-		req = req.WithContext(ctx)
-		go func() {
-			resp, err := client.Do(req)
-			if err != nil {
-				errorLog.Write([]byte(fmt.Sprintf("Failed to send web request: %s\n", err)))
-				return
-			}
-			defer resp.Body.Close()
-			if resp.StatusCode != 200 {
-				errorLog.Write([]byte(fmt.Sprintf("Bad Response: %d - %s\n", resp.StatusCode, resp.Status)))
-			}
-			return
-		}()
-		return nil
-	}
+	batch, events := n.swapBuffer()
+	return n._Post(batch, events)
 }
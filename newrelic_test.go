@@ -0,0 +1,106 @@
+package newrelicEvents
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// capturePoster decompresses and decodes each posted body into raw JSON
+// messages, one per event in the batch, and hands them to fn.
+func capturePoster(t *testing.T, fn func(events []json.RawMessage)) func(*http.Request) error {
+	t.Helper()
+	return func(req *http.Request) error {
+		gz, err := gzip.NewReader(req.Body)
+		if err != nil {
+			t.Fatalf("gzip.NewReader: %v", err)
+		}
+		defer gz.Close()
+		body, err := io.ReadAll(gz)
+		if err != nil {
+			t.Fatalf("reading gzip body: %v", err)
+		}
+		var events []json.RawMessage
+		if err := json.Unmarshal(body, &events); err != nil {
+			t.Fatalf("batch is not a valid JSON array: %v (body: %s)", err, body)
+		}
+		fn(events)
+		return nil
+	}
+}
+
+// TestRecordEventBatchEncoding exercises the streaming encoder end to end:
+// several events recorded independently must come out the other side of
+// compress/Sync as a single well-formed JSON array, one element per event.
+func TestRecordEventBatchEncoding(t *testing.T) {
+	var got []json.RawMessage
+	n := NewWithConfig("acct", "license")
+	n.Poster = capturePoster(t, func(events []json.RawMessage) { got = events })
+
+	for i := 0; i < 3; i++ {
+		if err := n.RecordEvent("test", map[string]interface{}{"n": i}); err != nil {
+			t.Fatalf("RecordEvent: %v", err)
+		}
+	}
+	if err := n.Sync(); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("got %d events in batch, want 3", len(got))
+	}
+}
+
+// TestRecordEventMaxBatchSize checks that size accounting against
+// MaxBatchSize is measured on the encoded, comma-joined buffer - not on
+// some other count - by forcing a flush after a known number of
+// fixed-size events.
+func TestRecordEventMaxBatchSize(t *testing.T) {
+	var batches [][]json.RawMessage
+	n := NewWithConfig("acct", "license")
+	n.Poster = capturePoster(t, func(events []json.RawMessage) {
+		batches = append(batches, events)
+	})
+
+	encoded, err := json.Marshal(map[string]interface{}{"eventType": "test", "n": 1})
+	if err != nil {
+		t.Fatalf("Marshal: %v", err)
+	}
+	// newBatchBuffer starts at 1 byte ('['); after k events of len(encoded)
+	// plus (k-1) commas the buffer crosses MaxBatchSize once k events have
+	// been written, so size MaxBatchSize triggers a flush after exactly 2.
+	n.MaxBatchSize = 1 + len(encoded) + 1 // '[' + one event + one comma
+
+	if err := n.RecordEvent("test", map[string]interface{}{"n": 1}); err != nil {
+		t.Fatalf("RecordEvent: %v", err)
+	}
+	if len(batches) != 0 {
+		t.Fatalf("flushed after 1 event, want no flush yet")
+	}
+	if err := n.RecordEvent("test", map[string]interface{}{"n": 2}); err != nil {
+		t.Fatalf("RecordEvent: %v", err)
+	}
+	if len(batches) != 1 || len(batches[0]) != 2 {
+		t.Fatalf("got batches %v, want one batch of 2 events", batches)
+	}
+}
+
+// TestRecordEventDropsOversizedEvent checks that an event over MaxEventSize
+// is rejected before ever reaching the batch buffer.
+func TestRecordEventDropsOversizedEvent(t *testing.T) {
+	n := NewWithConfig("acct", "license")
+	n.MaxEventSize = 10
+	err := n.RecordEvent("test", map[string]interface{}{"value": "way more than ten bytes of payload"})
+	if err == nil {
+		t.Fatal("expected an error for an oversized event, got nil")
+	}
+	var tooLarge *ErrEventTooLarge
+	if !errors.As(err, &tooLarge) {
+		t.Fatalf("got error %v, want *ErrEventTooLarge", err)
+	}
+	if n.Estimated() != 1 {
+		t.Fatalf("Estimated() = %d, want 1 (just the buffer's opening '[')", n.Estimated())
+	}
+}
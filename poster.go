@@ -0,0 +1,212 @@
+package newrelicEvents
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PostError is returned by the built-in Posters for a non-200 response. It
+// carries the raw response body - which for New Relic Insights is a JSON
+// error explaining what went wrong (invalid attribute, quota exceeded,
+// etc.) - plus enough classification for a retry wrapper to act on without
+// re-parsing the status code itself.
+type PostError struct {
+	StatusCode int
+	Body       []byte
+	Retryable  bool
+	RetryAfter time.Duration
+}
+
+func (e *PostError) Error() string {
+	status := fmt.Sprintf("%d %s", e.StatusCode, http.StatusText(e.StatusCode))
+	if msg := parseAPIErrorMessage(e.Body); msg != "" {
+		return fmt.Sprintf("newrelicEvents: post failed: %s: %s", status, msg)
+	}
+	return fmt.Sprintf("newrelicEvents: post failed: %s", status)
+}
+
+// parseAPIErrorMessage extracts New Relic Insights' {"error":"..."}
+// response body shape, returning "" if body doesn't match it.
+func parseAPIErrorMessage(body []byte) string {
+	var parsed struct {
+		Error string `json:"error"`
+	}
+	if err := json.Unmarshal(body, &parsed); err != nil {
+		return ""
+	}
+	return parsed.Error
+}
+
+// newPostError builds a *PostError from a non-200 response, classifying
+// 408/429/5xx as retryable and any other 4xx as terminal, and parsing a
+// Retry-After header if the server sent one.
+func newPostError(resp *http.Response) *PostError {
+	body, _ := io.ReadAll(resp.Body)
+	return &PostError{
+		StatusCode: resp.StatusCode,
+		Body:       body,
+		Retryable:  isRetryableStatus(resp.StatusCode),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After")),
+	}
+}
+
+func isRetryableStatus(code int) bool {
+	return code == http.StatusRequestTimeout || code == http.StatusTooManyRequests || code >= 500
+}
+
+// parseRetryAfter accepts either form New Relic (and HTTP generally) sends
+// Retry-After in: a number of seconds, or an HTTP-date.
+func parseRetryAfter(v string) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if when, err := http.ParseTime(v); err == nil {
+		if d := time.Until(when); d > 0 {
+			return d
+		}
+	}
+	return 0
+}
+
+///////////////////////////////////////////////////////////////////////////
+
+// StandardPost performs a synchronous POST with a 30 second timeout,
+// returning a *PostError for any non-200 response.
+func StandardPost(client *http.Client) func(*http.Request) error {
+	return func(req *http.Request) error {
+		ctx, canFunc := context.WithTimeout(context.Background(), time.Second*30)
+		defer canFunc()
+		req = req.WithContext(ctx)
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != 200 {
+			return newPostError(resp)
+		}
+		return nil
+	}
+}
+
+///////////////////////////////////////////////////////////////////////////
+
+// AsyncPost fires the POST in a goroutine and always returns nil
+// immediately; failures (including a non-200 *PostError) are written to
+// errorLog. Because the caller has already moved on by the time the
+// goroutine resolves, deliverWithRetry's own Metrics/OnDropped reporting
+// never runs for such a failure, so AsyncPost reports failures itself:
+// metrics is passed
+// FlushErrors, and onDropped, if non-nil, is called the same way OnDropped
+// is elsewhere, with the request body recovered via req.GetBody.
+func AsyncPost(ctx context.Context, client http.Client, errorLog io.Writer, metrics Metrics, onDropped func(name string, payload []byte, reason error)) func(*http.Request) error {
+	if metrics == nil {
+		metrics = noopMetrics{}
+	}
+	return func(req *http.Request) error {
+		req = req.WithContext(ctx)
+		go func() {
+			resp, err := client.Do(req)
+			if err != nil {
+				errorLog.Write([]byte(fmt.Sprintf("Failed to send web request: %s\n", err)))
+				metrics.FlushErrors("transport")
+				if onDropped != nil {
+					onDropped("", asyncPayload(req), err)
+				}
+				return
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != 200 {
+				postErr := newPostError(resp)
+				errorLog.Write([]byte(postErr.Error() + "\n"))
+				metrics.FlushErrors(flushErrorKind(postErr))
+				if onDropped != nil {
+					onDropped("", asyncPayload(req), postErr)
+				}
+			}
+		}()
+		return nil
+	}
+}
+
+// asyncPayload recovers the request body for onDropped via req.GetBody,
+// since client.Do has already consumed req.Body by the time a failure is
+// known.
+func asyncPayload(req *http.Request) []byte {
+	if req.GetBody == nil {
+		return nil
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil
+	}
+	data, _ := io.ReadAll(body)
+	return data
+}
+
+///////////////////////////////////////////////////////////////////////////
+
+// RetryingPost wraps a Poster with bounded retry and backoff driven by
+// *PostError's classification - 408/429/5xx are retried (honoring a
+// Retry-After the server sent), anything else is returned immediately.
+// It requires req.GetBody to be set, which http.NewRequest populates
+// automatically for the *bytes.Reader bodies this package builds.
+// maxAttempts counts retries after the first call, so poster is called at
+// most maxAttempts+1 times.
+//
+// RetryingPost is for callers who post batches themselves (via Sync or a
+// direct Poster call) and never call Start: the worker pool started by
+// Start already retries deliverWithRetry's own way, and wrapping a
+// Newrelic.Poster that's used with Start in RetryingPost would retry each
+// attempt twice, compounding both backoffs.
+func RetryingPost(poster func(req *http.Request) error, maxAttempts int) func(req *http.Request) error {
+	return func(req *http.Request) error {
+		backoff := initialBackoff
+		var lastErr error
+		for attempt := 0; attempt <= maxAttempts; attempt++ {
+			if attempt > 0 {
+				if req.GetBody == nil {
+					return lastErr
+				}
+				body, err := req.GetBody()
+				if err != nil {
+					return err
+				}
+				req.Body = body
+			}
+
+			lastErr = poster(req)
+			if lastErr == nil {
+				return nil
+			}
+
+			var postErr *PostError
+			if !errors.As(lastErr, &postErr) || !postErr.Retryable || attempt == maxAttempts {
+				return lastErr
+			}
+
+			wait := jitter(backoff)
+			if postErr.RetryAfter > 0 {
+				wait = postErr.RetryAfter
+			}
+			time.Sleep(wait)
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+		}
+		return lastErr
+	}
+}
@@ -0,0 +1,200 @@
+package newrelicEvents
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+// roundTripFunc lets a test fake an *http.Client's transport without a real
+// server.
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// recordingMetrics is a Metrics that only tracks FlushErrors calls, which is
+// all these tests need to assert on.
+type recordingMetrics struct {
+	mu          sync.Mutex
+	flushErrors []string
+}
+
+func (m *recordingMetrics) EventsRecorded(name string)               {}
+func (m *recordingMetrics) EventsDropped(name string, reason string) {}
+func (m *recordingMetrics) BatchesFlushed(bytes int, events int)     {}
+func (m *recordingMetrics) FlushDuration(d time.Duration)            {}
+func (m *recordingMetrics) FlushErrors(kind string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.flushErrors = append(m.flushErrors, kind)
+}
+
+func (m *recordingMetrics) kinds() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]string(nil), m.flushErrors...)
+}
+
+func TestIsRetryableStatus(t *testing.T) {
+	cases := []struct {
+		code int
+		want bool
+	}{
+		{http.StatusOK, false},
+		{http.StatusBadRequest, false},
+		{http.StatusNotFound, false},
+		{http.StatusRequestTimeout, true},
+		{http.StatusTooManyRequests, true},
+		{http.StatusInternalServerError, true},
+		{http.StatusServiceUnavailable, true},
+	}
+	for _, c := range cases {
+		if got := isRetryableStatus(c.code); got != c.want {
+			t.Errorf("isRetryableStatus(%d) = %v, want %v", c.code, got, c.want)
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	future := time.Now().Add(10 * time.Second).UTC().Format(http.TimeFormat)
+	past := time.Now().Add(-10 * time.Second).UTC().Format(http.TimeFormat)
+
+	cases := []struct {
+		name  string
+		value string
+		want  time.Duration
+	}{
+		{"empty", "", 0},
+		{"seconds", "120", 120 * time.Second},
+		{"negative seconds", "-5", 0},
+		{"garbage", "not-a-date", 0},
+		{"past http-date", past, 0},
+	}
+	for _, c := range cases {
+		if got := parseRetryAfter(c.value); got != c.want {
+			t.Errorf("parseRetryAfter(%q) = %v, want %v", c.value, got, c.want)
+		}
+	}
+
+	got := parseRetryAfter(future)
+	if got <= 0 || got > 10*time.Second {
+		t.Errorf("parseRetryAfter(%q) = %v, want a positive duration <= 10s", future, got)
+	}
+}
+
+func TestAsyncPostTransportFailureReportsMetrics(t *testing.T) {
+	client := http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return nil, errors.New("connection refused")
+	})}
+	metrics := &recordingMetrics{}
+	dropped := make(chan error, 1)
+
+	poster := AsyncPost(context.Background(), client, io.Discard, metrics, func(name string, payload []byte, reason error) {
+		dropped <- reason
+	})
+
+	req, err := http.NewRequest("POST", "http://example.invalid/v1/events", bytes.NewReader([]byte("body")))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if err := poster(req); err != nil {
+		t.Fatalf("AsyncPost poster returned %v, want nil (fire-and-forget)", err)
+	}
+
+	select {
+	case reason := <-dropped:
+		if reason == nil {
+			t.Fatal("onDropped called with a nil reason")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("onDropped was never called")
+	}
+	if kinds := metrics.kinds(); len(kinds) != 1 || kinds[0] != "transport" {
+		t.Fatalf("FlushErrors calls = %v, want [\"transport\"]", kinds)
+	}
+}
+
+func TestAsyncPostHTTPFailureReportsMetrics(t *testing.T) {
+	client := http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		return &http.Response{
+			StatusCode: http.StatusInternalServerError,
+			Body:       io.NopCloser(bytes.NewReader([]byte(`{"error":"boom"}`))),
+			Header:     make(http.Header),
+		}, nil
+	})}
+	metrics := &recordingMetrics{}
+	dropped := make(chan error, 1)
+
+	poster := AsyncPost(context.Background(), client, io.Discard, metrics, func(name string, payload []byte, reason error) {
+		dropped <- reason
+	})
+
+	req, err := http.NewRequest("POST", "http://example.invalid/v1/events", bytes.NewReader([]byte("body")))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if err := poster(req); err != nil {
+		t.Fatalf("AsyncPost poster returned %v, want nil (fire-and-forget)", err)
+	}
+
+	select {
+	case reason := <-dropped:
+		var postErr *PostError
+		if !errors.As(reason, &postErr) {
+			t.Fatalf("onDropped reason = %v, want a *PostError", reason)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("onDropped was never called")
+	}
+	if kinds := metrics.kinds(); len(kinds) != 1 || kinds[0] != "retryable" {
+		t.Fatalf("FlushErrors calls = %v, want [\"retryable\"]", kinds)
+	}
+}
+
+func TestRetryingPostRetriesUntilSuccess(t *testing.T) {
+	var attempts int
+	poster := func(req *http.Request) error {
+		attempts++
+		if attempts < 3 {
+			return &PostError{StatusCode: 503, Retryable: true, RetryAfter: time.Millisecond}
+		}
+		return nil
+	}
+
+	req, err := http.NewRequest("POST", "http://example.invalid/v1/events", bytes.NewReader([]byte("body")))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if err := RetryingPost(poster, 5)(req); err != nil {
+		t.Fatalf("RetryingPost = %v, want nil after eventual success", err)
+	}
+	if attempts != 3 {
+		t.Fatalf("attempts = %d, want 3", attempts)
+	}
+}
+
+func TestRetryingPostStopsOnTerminalError(t *testing.T) {
+	var attempts int
+	poster := func(req *http.Request) error {
+		attempts++
+		return &PostError{StatusCode: 400, Retryable: false}
+	}
+
+	req, err := http.NewRequest("POST", "http://example.invalid/v1/events", bytes.NewReader([]byte("body")))
+	if err != nil {
+		t.Fatalf("NewRequest: %v", err)
+	}
+	if err := RetryingPost(poster, 5)(req); err == nil {
+		t.Fatal("RetryingPost = nil, want the terminal error")
+	}
+	if attempts != 1 {
+		t.Fatalf("attempts = %d, want 1 (no retry on a terminal error)", attempts)
+	}
+}
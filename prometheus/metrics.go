@@ -0,0 +1,83 @@
+// Package prometheus provides a promhttp-compatible newrelicEvents.Metrics
+// implementation: each client method increments or observes a Prometheus
+// metric that a promhttp.Handler can expose for scraping.
+package prometheus
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics implements newrelicEvents.Metrics by reporting to a set of
+// registered Prometheus collectors.
+type Metrics struct {
+	eventsRecorded *prometheus.CounterVec
+	eventsDropped  *prometheus.CounterVec
+	batchesFlushed prometheus.Counter
+	eventsFlushed  prometheus.Counter
+	bytesFlushed   prometheus.Counter
+	flushDuration  prometheus.Histogram
+	flushErrors    *prometheus.CounterVec
+}
+
+// New registers newrelic_events_* metrics with reg and returns a Metrics
+// reporting to them. Pass prometheus.DefaultRegisterer for the default
+// registry used by promhttp.Handler().
+func New(reg prometheus.Registerer) *Metrics {
+	factory := promauto.With(reg)
+	return &Metrics{
+		eventsRecorded: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "newrelic_events_recorded_total",
+			Help: "Events accepted by RecordEvent, by event name.",
+		}, []string{"name"}),
+		eventsDropped: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "newrelic_events_dropped_total",
+			Help: "Events rejected by RecordEvent, by event name and reason.",
+		}, []string{"name", "reason"}),
+		batchesFlushed: factory.NewCounter(prometheus.CounterOpts{
+			Name: "newrelic_events_batches_flushed_total",
+			Help: "Batches successfully POSTed to New Relic.",
+		}),
+		eventsFlushed: factory.NewCounter(prometheus.CounterOpts{
+			Name: "newrelic_events_flushed_total",
+			Help: "Events successfully POSTed to New Relic.",
+		}),
+		bytesFlushed: factory.NewCounter(prometheus.CounterOpts{
+			Name: "newrelic_events_bytes_flushed_total",
+			Help: "Gzipped bytes successfully POSTed to New Relic.",
+		}),
+		flushDuration: factory.NewHistogram(prometheus.HistogramOpts{
+			Name:    "newrelic_events_flush_duration_seconds",
+			Help:    "Time spent POSTing a single batch to New Relic.",
+			Buckets: prometheus.DefBuckets,
+		}),
+		flushErrors: factory.NewCounterVec(prometheus.CounterOpts{
+			Name: "newrelic_events_flush_errors_total",
+			Help: "Flush attempts that ended in an error, by kind.",
+		}, []string{"kind"}),
+	}
+}
+
+func (m *Metrics) EventsRecorded(name string) {
+	m.eventsRecorded.WithLabelValues(name).Inc()
+}
+
+func (m *Metrics) EventsDropped(name string, reason string) {
+	m.eventsDropped.WithLabelValues(name, reason).Inc()
+}
+
+func (m *Metrics) BatchesFlushed(bytes int, events int) {
+	m.batchesFlushed.Inc()
+	m.eventsFlushed.Add(float64(events))
+	m.bytesFlushed.Add(float64(bytes))
+}
+
+func (m *Metrics) FlushDuration(d time.Duration) {
+	m.flushDuration.Observe(d.Seconds())
+}
+
+func (m *Metrics) FlushErrors(kind string) {
+	m.flushErrors.WithLabelValues(kind).Inc()
+}
@@ -0,0 +1,265 @@
+package newrelicEvents
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Store persists prepared, gzipped batches so a crash between accepting
+// them and successfully POSTing them doesn't silently lose telemetry.
+// Pending's order is the order batches should be retried in.
+type Store interface {
+	Append(batch []byte) (id string, err error)
+	Pending() ([]string, error)
+	Load(id string) ([]byte, error)
+	Delete(id string) error
+}
+
+// persist hands body to n.Store, reporting a failure to persist through
+// OnDropped as a durability warning rather than a delivery failure - a
+// batch that can't be persisted is still attempted, just without the
+// crash-safety guarantee. The returned id/persisted pair is what forget
+// needs once delivery is resolved.
+func (n *Newrelic) persist(body []byte) (id string, persisted bool) {
+	pid, err := n.Store.Append(body)
+	if err != nil {
+		if n.OnDropped != nil {
+			n.OnDropped("", body, fmt.Errorf("newrelicEvents: failed to persist batch: %w", err))
+		}
+		return "", false
+	}
+	return pid, true
+}
+
+// forget removes a batch from the Store once its delivery attempt (success
+// or final drop) is resolved. It is a no-op if persist didn't succeed.
+func (n *Newrelic) forget(id string, persisted bool, body []byte) {
+	if !persisted {
+		return
+	}
+	if err := n.Store.Delete(id); err != nil && n.OnDropped != nil {
+		n.OnDropped("", body, fmt.Errorf("newrelicEvents: failed to delete persisted batch: %w", err))
+	}
+}
+
+// QueueDepth reports how many batches are currently queued in the Store,
+// for operators wiring up depth-based alerting.
+func (n *Newrelic) QueueDepth() (int, error) {
+	pending, err := n.Store.Pending()
+	if err != nil {
+		return 0, err
+	}
+	return len(pending), nil
+}
+
+// NewWithStore wraps NewWithConfig, attaching store as the durability
+// backend and replaying any batches left over from a previous process
+// before accepting new events. A batch that fails to replay is left in the
+// store so it's retried on the next restart instead of being discarded; the
+// first such error is returned alongside the otherwise-usable client.
+func NewWithStore(accountID, license string, store Store, opts ...Option) (*Newrelic, error) {
+	n := NewWithConfig(accountID, license, opts...)
+	n.Store = store
+
+	pending, err := store.Pending()
+	if err != nil {
+		return nil, fmt.Errorf("newrelicEvents: listing pending batches: %w", err)
+	}
+
+	var replayErr error
+	for _, id := range pending {
+		body, err := store.Load(id)
+		if err != nil {
+			if replayErr == nil {
+				replayErr = err
+			}
+			continue
+		}
+		if err := n.postBody(body); err != nil {
+			if replayErr == nil {
+				replayErr = err
+			}
+			continue
+		}
+		if err := store.Delete(id); err != nil && replayErr == nil {
+			replayErr = err
+		}
+	}
+	return n, replayErr
+}
+
+///////////////////////////////////////////////////////////////////////////
+
+// MemoryStore is the default Store: it keeps queued batches in memory, so
+// it offers no durability across restarts but needs no configuration.
+type MemoryStore struct {
+	mu      sync.Mutex
+	order   []string
+	batches map[string][]byte
+	seq     uint64
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{batches: map[string][]byte{}}
+}
+
+func (s *MemoryStore) Append(batch []byte) (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.seq++
+	id := fmt.Sprintf("%020d", s.seq)
+	s.batches[id] = append([]byte(nil), batch...)
+	s.order = append(s.order, id)
+	return id, nil
+}
+
+func (s *MemoryStore) Pending() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]string, len(s.order))
+	copy(out, s.order)
+	return out, nil
+}
+
+func (s *MemoryStore) Load(id string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	b, ok := s.batches[id]
+	if !ok {
+		return nil, fmt.Errorf("newrelicEvents: no such batch %q", id)
+	}
+	return b, nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.batches[id]; !ok {
+		return nil
+	}
+	delete(s.batches, id)
+	for i, oid := range s.order {
+		if oid == id {
+			s.order = append(s.order[:i], s.order[i+1:]...)
+			break
+		}
+	}
+	return nil
+}
+
+///////////////////////////////////////////////////////////////////////////
+
+// DiskStore persists batches as one gzip file per batch under Dir, so they
+// survive a crash or restart between being accepted and being successfully
+// POSTed. MaxBatches caps how many queued files are kept; once exceeded,
+// the oldest batch is evicted FIFO. A zero MaxBatches means unbounded.
+type DiskStore struct {
+	Dir        string
+	MaxBatches int
+
+	mu  sync.Mutex
+	seq uint64
+}
+
+// NewDiskStore creates dir (and any missing parents) and returns a DiskStore
+// rooted there.
+func NewDiskStore(dir string, maxBatches int) (*DiskStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	return &DiskStore{Dir: dir, MaxBatches: maxBatches}, nil
+}
+
+func (s *DiskStore) path(id string) string {
+	return filepath.Join(s.Dir, id+".batch.gz")
+}
+
+func (s *DiskStore) Append(batch []byte) (string, error) {
+	s.mu.Lock()
+	s.seq++
+	id := fmt.Sprintf("%020d-%020d", time.Now().UnixNano(), s.seq)
+	s.mu.Unlock()
+
+	f, err := os.OpenFile(s.path(id), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o644)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	gz := gzip.NewWriter(f)
+	if _, err := gz.Write(batch); err != nil {
+		return "", err
+	}
+	if err := gz.Close(); err != nil {
+		return "", err
+	}
+
+	if err := s.evict(); err != nil {
+		return id, err
+	}
+	return id, nil
+}
+
+func (s *DiskStore) Pending() ([]string, error) {
+	entries, err := os.ReadDir(s.Dir)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if e.IsDir() || !strings.HasSuffix(e.Name(), ".batch.gz") {
+			continue
+		}
+		ids = append(ids, strings.TrimSuffix(e.Name(), ".batch.gz"))
+	}
+	// ids are zero-padded timestamp-seq pairs, so lexical order is FIFO order.
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (s *DiskStore) Load(id string) ([]byte, error) {
+	f, err := os.Open(s.path(id))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+	return io.ReadAll(gz)
+}
+
+func (s *DiskStore) Delete(id string) error {
+	err := os.Remove(s.path(id))
+	if err != nil && os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *DiskStore) evict() error {
+	if s.MaxBatches <= 0 {
+		return nil
+	}
+	ids, err := s.Pending()
+	if err != nil {
+		return err
+	}
+	for len(ids) > s.MaxBatches {
+		if err := s.Delete(ids[0]); err != nil {
+			return err
+		}
+		ids = ids[1:]
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package newrelicEvents
+
+import (
+	"bytes"
+	"testing"
+)
+
+// TestDiskStoreRoundTrip checks the basic Append/Pending/Load/Delete cycle:
+// a batch written to the store comes back byte-identical, Pending reports
+// it in FIFO order, and Delete removes it for good.
+func TestDiskStoreRoundTrip(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir(), 0)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+
+	want := []byte(`[{"eventType":"test","n":1}]`)
+	id, err := store.Append(want)
+	if err != nil {
+		t.Fatalf("Append: %v", err)
+	}
+
+	pending, err := store.Pending()
+	if err != nil {
+		t.Fatalf("Pending: %v", err)
+	}
+	if len(pending) != 1 || pending[0] != id {
+		t.Fatalf("Pending() = %v, want [%q]", pending, id)
+	}
+
+	got, err := store.Load(id)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Fatalf("Load() = %q, want %q", got, want)
+	}
+
+	if err := store.Delete(id); err != nil {
+		t.Fatalf("Delete: %v", err)
+	}
+	pending, err = store.Pending()
+	if err != nil {
+		t.Fatalf("Pending after Delete: %v", err)
+	}
+	if len(pending) != 0 {
+		t.Fatalf("Pending() after Delete = %v, want none", pending)
+	}
+}
+
+// TestDiskStoreFIFOEviction checks that once MaxBatches is exceeded, Append
+// evicts the oldest batch first rather than the most recently written one.
+func TestDiskStoreFIFOEviction(t *testing.T) {
+	store, err := NewDiskStore(t.TempDir(), 2)
+	if err != nil {
+		t.Fatalf("NewDiskStore: %v", err)
+	}
+
+	first, err := store.Append([]byte("first"))
+	if err != nil {
+		t.Fatalf("Append(first): %v", err)
+	}
+	if _, err := store.Append([]byte("second")); err != nil {
+		t.Fatalf("Append(second): %v", err)
+	}
+	third, err := store.Append([]byte("third"))
+	if err != nil {
+		t.Fatalf("Append(third): %v", err)
+	}
+
+	pending, err := store.Pending()
+	if err != nil {
+		t.Fatalf("Pending: %v", err)
+	}
+	if len(pending) != 2 {
+		t.Fatalf("Pending() = %v, want 2 batches after evicting the oldest", pending)
+	}
+	if _, err := store.Load(first); err == nil {
+		t.Fatalf("Load(first) succeeded, want the oldest batch to have been evicted")
+	}
+	if _, err := store.Load(third); err != nil {
+		t.Fatalf("Load(third): %v, want the newest batch to survive eviction", err)
+	}
+}